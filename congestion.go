@@ -0,0 +1,175 @@
+package screen
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+// defaultBitrateLadder 是拥塞控制在其间切换的可选码率挡位，单位bps
+var defaultBitrateLadder = []int{500_000, 1_000_000, 2_000_000, 4_000_000, 8_000_000}
+
+const (
+	// congestionWindow 是参与中位数计算的最近带宽样本个数，约覆盖最近5秒
+	congestionWindow = 5
+	// congestionTickInterval 是每次重新评估目标码率的周期
+	congestionTickInterval = 2 * time.Second
+	// congestionUpThreshold 是上调到下一挡所需超出的比例
+	congestionUpThreshold = 1.2
+	// congestionUpStreakNeeded 是连续满足上调条件所需的tick数（迟滞）
+	congestionUpStreakNeeded = 3
+	// congestionDownThreshold 是低于当前挡位多少比例时立即下调
+	congestionDownThreshold = 0.85
+	// twccPacketBytes 是估算TWCC带宽时假定的典型RTP包大小（字节）。TWCC反馈
+	// 本身不携带每个包的实际发送字节数，这里只能近似；真正精确的估计需要
+	// 发送端维护一份按序列号记录实际发送大小的台账，目前没有实现
+	twccPacketBytes = 1200
+)
+
+// CongestionController 依据RTCP REMB/TWCC反馈的带宽估计，在一个码率梯度中
+// 选择目标码率并通过DeviceConnector.SetBitrate下发给scrcpy_server。
+// 升挡需要连续多个周期的余量以避免抖动，降挡则立即生效。
+type CongestionController struct {
+	dc     *DeviceConnector
+	ladder []int
+
+	mu       sync.Mutex
+	samples  []int
+	rungIdx  int
+	upStreak int
+}
+
+// NewCongestionController 创建一个使用默认码率梯度、从最低挡开始的控制器
+func NewCongestionController(dc *DeviceConnector) *CongestionController {
+	return &CongestionController{dc: dc, ladder: defaultBitrateLadder}
+}
+
+// Feed 解析从rtpSender.Read得到的RTCP包，提取REMB目标码率或TWCC反馈作为
+// 带宽样本
+func (c *CongestionController) Feed(pkts []rtcp.Packet) {
+	for _, pkt := range pkts {
+		switch p := pkt.(type) {
+		case *rtcp.ReceiverEstimatedMaximumBitrate:
+			c.AddEstimate(int(p.Bitrate))
+		case *rtcp.TransportLayerCC:
+			c.feedTWCC(p)
+		}
+	}
+}
+
+// feedTWCC 从一次TWCC反馈里粗略估算带宽：TWCC只报告每个包"收到与否+相对
+// 上一个包的到达时间差"，不携带实际发送字节数，这里用twccPacketBytes当作
+// 典型包大小做近似——(被确认收到的包数*假定包大小) / 这批反馈覆盖的时间
+// 跨度。精度不如REMB，但在某些只发TWCC不发REMB的浏览器上是唯一信号
+func (c *CongestionController) feedTWCC(pkt *rtcp.TransportLayerCC) {
+	var receivedCount int
+	var totalMicros int64
+	for _, d := range pkt.RecvDeltas {
+		if d.Type == rtcp.TypeTCCPacketNotReceived {
+			continue
+		}
+		receivedCount++
+		if d.Delta > 0 {
+			totalMicros += d.Delta
+		}
+	}
+	if receivedCount == 0 || totalMicros <= 0 {
+		return
+	}
+
+	bps := int64(receivedCount*twccPacketBytes*8) * 1_000_000 / totalMicros
+	c.AddEstimate(int(bps))
+}
+
+// AddEstimate 记录一次带宽估计样本（bps），供TWCC等其它来源复用
+func (c *CongestionController) AddEstimate(bps int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.samples = append(c.samples, bps)
+	if len(c.samples) > congestionWindow {
+		c.samples = c.samples[len(c.samples)-congestionWindow:]
+	}
+}
+
+// Run 每隔congestionTickInterval重新评估一次目标码率，直到ctx被取消
+func (c *CongestionController) Run(ctx context.Context) {
+	ticker := time.NewTicker(congestionTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.tick()
+		}
+	}
+}
+
+func (c *CongestionController) tick() {
+	c.mu.Lock()
+	if len(c.samples) == 0 {
+		c.mu.Unlock()
+		return
+	}
+	estimate := median(c.samples)
+	rung := c.rungIdx
+	c.mu.Unlock()
+
+	current := c.ladder[rung]
+
+	switch {
+	case estimate < int(float64(current)*congestionDownThreshold):
+		c.resetUpStreak()
+		if rung > 0 {
+			c.setRung(rung - 1)
+		}
+	case rung+1 < len(c.ladder) && estimate >= int(float64(c.ladder[rung+1])*congestionUpThreshold):
+		if c.bumpUpStreak() >= congestionUpStreakNeeded {
+			c.resetUpStreak()
+			c.setRung(rung + 1)
+		}
+	default:
+		c.resetUpStreak()
+	}
+}
+
+func (c *CongestionController) setRung(idx int) {
+	c.mu.Lock()
+	c.rungIdx = idx
+	bps := c.ladder[idx]
+	c.mu.Unlock()
+
+	if err := c.dc.SetBitrate(bps); err != nil {
+		log.Printf("failed to set bitrate: %s\n", err.Error())
+	}
+}
+
+func (c *CongestionController) bumpUpStreak() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.upStreak++
+	return c.upStreak
+}
+
+func (c *CongestionController) resetUpStreak() {
+	c.mu.Lock()
+	c.upStreak = 0
+	c.mu.Unlock()
+}
+
+func median(samples []int) int {
+	sorted := append([]int(nil), samples...)
+	sort.Ints(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
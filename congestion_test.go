@@ -0,0 +1,61 @@
+package screen
+
+import "testing"
+
+func TestMedian(t *testing.T) {
+	cases := []struct {
+		samples []int
+		want    int
+	}{
+		{[]int{5}, 5},
+		{[]int{1, 3, 2}, 2},
+		{[]int{1, 2, 3, 4}, 2},
+		{[]int{4, 1, 3, 2}, 2},
+	}
+
+	for _, c := range cases {
+		if got := median(c.samples); got != c.want {
+			t.Errorf("median(%v) = %d, want %d", c.samples, got, c.want)
+		}
+	}
+}
+
+func TestTickDownRungIsImmediate(t *testing.T) {
+	c := NewCongestionController(&DeviceConnector{})
+	c.rungIdx = 2 // 2_000_000
+
+	c.samples = []int{int(float64(c.ladder[2]) * 0.5)}
+	c.tick()
+
+	if c.rungIdx != 1 {
+		t.Fatalf("expected rung to drop to 1, got %d", c.rungIdx)
+	}
+}
+
+func TestTickUpRungNeedsStreak(t *testing.T) {
+	c := NewCongestionController(&DeviceConnector{})
+	c.rungIdx = 0 // 500_000
+
+	above := int(float64(c.ladder[1]) * (congestionUpThreshold + 0.1))
+	c.samples = []int{above}
+
+	for i := 0; i < congestionUpStreakNeeded-1; i++ {
+		c.tick()
+		if c.rungIdx != 0 {
+			t.Fatalf("rung should not have moved before the streak completes, moved on tick %d", i+1)
+		}
+	}
+
+	c.tick()
+	if c.rungIdx != 1 {
+		t.Fatalf("expected rung to rise to 1 once the streak completes, got %d", c.rungIdx)
+	}
+}
+
+func TestTickWithNoSamplesIsNoop(t *testing.T) {
+	c := NewCongestionController(&DeviceConnector{})
+	c.tick()
+	if c.rungIdx != 0 {
+		t.Fatalf("rung should stay put with no samples, got %d", c.rungIdx)
+	}
+}
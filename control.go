@@ -0,0 +1,90 @@
+package screen
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// 数据通道（"control"）上每条消息的op，标识负载的类型
+const (
+	ControlOpTouch  = 1
+	ControlOpKey    = 2
+	ControlOpText   = 3
+	ControlOpRotate = 4
+)
+
+// controlHeaderSize 是消息头的大小：uint8 op + uint16 len
+const controlHeaderSize = 1 + 2
+
+// 各定长负载的大小（字节）
+const (
+	// touchEventSize: action uint8, pointerId uint8, x/y int32, pressure float32, screenW/screenH int32
+	touchEventSize = 1 + 1 + 4 + 4 + 4 + 4 + 4
+	// keyEventSize: action uint8, keycode int32, metastate int32
+	keyEventSize = 1 + 4 + 4
+)
+
+// HandleControlMessage 解析一条来自"control" DataChannel的消息——帧格式为
+// {op uint8, len uint16, payload []byte}——校验负载长度后转发为对应的
+// CMD_INJECT_*/CMD_ROTATE命令下发给scrcpy_server
+func (dc *DeviceConnector) HandleControlMessage(data []byte) error {
+	if len(data) < controlHeaderSize {
+		return fmt.Errorf("control message too short: %d bytes", len(data))
+	}
+
+	op := data[0]
+	length := int(binary.LittleEndian.Uint16(data[1:3]))
+	payload := data[controlHeaderSize:]
+	if length != len(payload) {
+		return fmt.Errorf("control message length mismatch: header says %d, got %d", length, len(payload))
+	}
+
+	switch op {
+	case ControlOpTouch:
+		return dc.handleTouchMessage(payload)
+	case ControlOpKey:
+		return dc.handleKeyMessage(payload)
+	case ControlOpText:
+		return dc.InjectText(string(payload))
+	case ControlOpRotate:
+		return dc.handleRotateMessage(payload)
+	default:
+		return fmt.Errorf("unknown control op: %d", op)
+	}
+}
+
+func (dc *DeviceConnector) handleTouchMessage(payload []byte) error {
+	if len(payload) != touchEventSize {
+		return fmt.Errorf("touch payload must be %d bytes, got %d", touchEventSize, len(payload))
+	}
+
+	action := payload[0]
+	pointerID := payload[1]
+	x := int32(binary.LittleEndian.Uint32(payload[2:6]))
+	y := int32(binary.LittleEndian.Uint32(payload[6:10]))
+	pressure := math.Float32frombits(binary.LittleEndian.Uint32(payload[10:14]))
+	screenW := int32(binary.LittleEndian.Uint32(payload[14:18]))
+	screenH := int32(binary.LittleEndian.Uint32(payload[18:22]))
+
+	return dc.InjectTouch(action, pointerID, x, y, pressure, screenW, screenH)
+}
+
+func (dc *DeviceConnector) handleKeyMessage(payload []byte) error {
+	if len(payload) != keyEventSize {
+		return fmt.Errorf("key payload must be %d bytes, got %d", keyEventSize, len(payload))
+	}
+
+	action := payload[0]
+	keycode := int32(binary.LittleEndian.Uint32(payload[1:5]))
+	metastate := int32(binary.LittleEndian.Uint32(payload[5:9]))
+
+	return dc.InjectKey(action, keycode, metastate)
+}
+
+func (dc *DeviceConnector) handleRotateMessage(payload []byte) error {
+	if len(payload) != 1 {
+		return fmt.Errorf("rotate payload must be 1 byte, got %d", len(payload))
+	}
+	return dc.Rotate(payload[0])
+}
@@ -0,0 +1,93 @@
+package screen
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func controlMessage(op byte, payload []byte) []byte {
+	msg := make([]byte, controlHeaderSize+len(payload))
+	msg[0] = op
+	binary.LittleEndian.PutUint16(msg[1:3], uint16(len(payload)))
+	copy(msg[controlHeaderSize:], payload)
+	return msg
+}
+
+func TestHandleControlMessageTouch(t *testing.T) {
+	dc := &DeviceConnector{}
+
+	payload := make([]byte, touchEventSize)
+	payload[0] = 1 // action
+	payload[1] = 2 // pointerID
+	binary.LittleEndian.PutUint32(payload[2:6], uint32(int32(100)))
+	binary.LittleEndian.PutUint32(payload[6:10], uint32(int32(200)))
+	binary.LittleEndian.PutUint32(payload[10:14], math.Float32bits(0.5))
+	binary.LittleEndian.PutUint32(payload[14:18], uint32(int32(1080)))
+	binary.LittleEndian.PutUint32(payload[18:22], uint32(int32(1920)))
+
+	err := dc.HandleControlMessage(controlMessage(ControlOpTouch, payload))
+	if err == nil || err.Error() != "connection not established" {
+		t.Fatalf("expected the touch message to reach InjectTouch/SendCommand, got %v", err)
+	}
+}
+
+func TestHandleControlMessageRotate(t *testing.T) {
+	dc := &DeviceConnector{}
+
+	err := dc.HandleControlMessage(controlMessage(ControlOpRotate, []byte{1}))
+	if err == nil || err.Error() != "connection not established" {
+		t.Fatalf("expected the rotate message to reach Rotate/SendCommand, got %v", err)
+	}
+}
+
+func TestHandleControlMessageTooShort(t *testing.T) {
+	dc := &DeviceConnector{}
+
+	if err := dc.HandleControlMessage([]byte{1, 2}); err == nil {
+		t.Fatal("expected an error for a message shorter than the header")
+	}
+}
+
+func TestHandleControlMessageLengthMismatch(t *testing.T) {
+	dc := &DeviceConnector{}
+
+	msg := controlMessage(ControlOpRotate, []byte{1})
+	binary.LittleEndian.PutUint16(msg[1:3], 99) // lie about the payload length
+
+	if err := dc.HandleControlMessage(msg); err == nil {
+		t.Fatal("expected an error when the header length doesn't match the payload")
+	}
+}
+
+func TestHandleControlMessageUnknownOp(t *testing.T) {
+	dc := &DeviceConnector{}
+
+	if err := dc.HandleControlMessage(controlMessage(0xFF, nil)); err == nil {
+		t.Fatal("expected an error for an unknown op")
+	}
+}
+
+func TestHandleTouchMessageWrongSize(t *testing.T) {
+	dc := &DeviceConnector{}
+
+	if err := dc.handleTouchMessage([]byte{1, 2, 3}); err == nil {
+		t.Fatal("expected an error for a malformed touch payload")
+	}
+}
+
+func TestHandleKeyMessageWrongSize(t *testing.T) {
+	dc := &DeviceConnector{}
+
+	if err := dc.handleKeyMessage([]byte{1, 2, 3}); err == nil {
+		t.Fatal("expected an error for a malformed key payload")
+	}
+}
+
+func TestHandleRotateMessageWrongSize(t *testing.T) {
+	dc := &DeviceConnector{}
+
+	if err := dc.handleRotateMessage([]byte{1, 2}); err == nil {
+		t.Fatal("expected an error for a malformed rotate payload")
+	}
+}
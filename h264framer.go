@@ -0,0 +1,152 @@
+package screen
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+)
+
+// NAL单元类型（ITU-T H.264 7.4.1），framer只需要关心这三种
+const (
+	nalUnitTypeIDR = 5
+	nalUnitTypeSPS = 7
+	nalUnitTypePPS = 8
+)
+
+var annexBStartCode = []byte{0, 0, 0, 1}
+
+// H264Framer 把scrcpy_server发来的原始字节流（Annex-B起始码分隔，或
+// 4字节大端长度前缀的AVCC）切分成NAL单元，缓存最近一次看到的SPS/PPS，
+// 并在每个IDR前补上缓存的SPS/PPS，使中途加入的消费者在它收到的第一个IDR处
+// 就有解码所需的参数集；在那之前的非IDR帧它仍无法解码。RequestKeyframe可用于
+// 主动要求服务器提前编码一个新的IDR。
+type H264Framer struct {
+	dc *DeviceConnector
+
+	mu  sync.Mutex
+	sps []byte
+	pps []byte
+}
+
+// NewH264Framer 创建一个绑定到给定DeviceConnector的H264Framer
+func NewH264Framer(dc *DeviceConnector) *H264Framer {
+	return &H264Framer{dc: dc}
+}
+
+// Frame 解析一段原始字节（通常是一个PKT_SCREEN_FRAME包的body），返回其中的
+// NAL单元（不含起始码），已在每个IDR前补齐缓存的SPS/PPS。SPS/PPS本身只被
+// 缓存，不会单独出现在返回值里
+func (f *H264Framer) Frame(data []byte) [][]byte {
+	var out [][]byte
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, nal := range splitNALUnits(data) {
+		switch nalUnitType(nal) {
+		case nalUnitTypeSPS:
+			f.sps = append([]byte(nil), nal...)
+			continue
+		case nalUnitTypePPS:
+			f.pps = append([]byte(nil), nal...)
+			continue
+		case nalUnitTypeIDR:
+			if f.sps != nil {
+				out = append(out, f.sps)
+			}
+			if f.pps != nil {
+				out = append(out, f.pps)
+			}
+		}
+		out = append(out, nal)
+	}
+
+	return out
+}
+
+// RequestKeyframe 请求服务器下发一个新的IDR，典型地由WebRTC端收到的
+// RTCP PLI/FIR触发
+func (f *H264Framer) RequestKeyframe() error {
+	return f.dc.SendCommand(CMD_REQUEST_IDR, nil)
+}
+
+// CachedParameterSets 返回目前缓存的SPS/PPS（如果尚未见过则为nil），
+// 供中途加入的消费者在下一个IDR到达前先行缓存
+func (f *H264Framer) CachedParameterSets() (sps, pps []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sps, f.pps
+}
+
+func nalUnitType(nal []byte) int {
+	if len(nal) == 0 {
+		return -1
+	}
+	return int(nal[0] & 0x1f)
+}
+
+// splitNALUnits 把一段缓冲切分成各个NAL单元（不含起始码/长度前缀）。
+// 自动判别是Annex-B起始码分隔还是AVCC长度前缀格式。
+func splitNALUnits(buf []byte) [][]byte {
+	if looksLikeAVCC(buf) {
+		return splitAVCC(buf)
+	}
+	return splitAnnexB(buf)
+}
+
+// looksLikeAVCC 判断缓冲是否为AVCC格式：AVCC以4字节NAL长度开头，而Annex-B
+// 总是以00 00 01或00 00 00 01起始码开头，二者几乎不会混淆
+func looksLikeAVCC(buf []byte) bool {
+	if len(buf) < 4 {
+		return false
+	}
+	return !bytes.HasPrefix(buf, []byte{0, 0, 1}) && !bytes.HasPrefix(buf, annexBStartCode)
+}
+
+func splitAnnexB(buf []byte) [][]byte {
+	var nals [][]byte
+
+	start := -1
+	for i := 0; i < len(buf); {
+		if ok, n := startCodeAt(buf, i); ok {
+			if start >= 0 {
+				nals = append(nals, buf[start:i])
+			}
+			i += n
+			start = i
+			continue
+		}
+		i++
+	}
+	if start >= 0 && start < len(buf) {
+		nals = append(nals, buf[start:])
+	}
+
+	return nals
+}
+
+func startCodeAt(buf []byte, i int) (bool, int) {
+	if i+4 <= len(buf) && buf[i] == 0 && buf[i+1] == 0 && buf[i+2] == 0 && buf[i+3] == 1 {
+		return true, 4
+	}
+	if i+3 <= len(buf) && buf[i] == 0 && buf[i+1] == 0 && buf[i+2] == 1 {
+		return true, 3
+	}
+	return false, 0
+}
+
+func splitAVCC(buf []byte) [][]byte {
+	var nals [][]byte
+
+	for len(buf) >= 4 {
+		length := int(binary.BigEndian.Uint32(buf[:4]))
+		buf = buf[4:]
+		if length <= 0 || length > len(buf) {
+			break
+		}
+		nals = append(nals, buf[:length])
+		buf = buf[length:]
+	}
+
+	return nals
+}
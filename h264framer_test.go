@@ -0,0 +1,81 @@
+package screen
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitNALUnitsAnnexB(t *testing.T) {
+	buf := append([]byte{0, 0, 0, 1}, 0x67)
+	buf = append(buf, []byte{0, 0, 1}...)
+	buf = append(buf, 0x68)
+	buf = append(buf, []byte{0, 0, 0, 1}...)
+	buf = append(buf, 0x65, 0xAA, 0xBB)
+
+	nals := splitNALUnits(buf)
+	if len(nals) != 3 {
+		t.Fatalf("expected 3 NAL units, got %d", len(nals))
+	}
+	if !bytes.Equal(nals[0], []byte{0x67}) {
+		t.Errorf("unexpected SPS NAL: %x", nals[0])
+	}
+	if !bytes.Equal(nals[1], []byte{0x68}) {
+		t.Errorf("unexpected PPS NAL: %x", nals[1])
+	}
+	if !bytes.Equal(nals[2], []byte{0x65, 0xAA, 0xBB}) {
+		t.Errorf("unexpected IDR NAL: %x", nals[2])
+	}
+}
+
+func TestSplitNALUnitsAVCC(t *testing.T) {
+	buf := append([]byte{0, 0, 0, 2}, 0x67, 0xAA)
+	buf = append(buf, 0, 0, 0, 3)
+	buf = append(buf, 0x65, 0xBB, 0xCC)
+
+	nals := splitAVCC(buf)
+	if len(nals) != 2 {
+		t.Fatalf("expected 2 NAL units, got %d", len(nals))
+	}
+	if !bytes.Equal(nals[0], []byte{0x67, 0xAA}) {
+		t.Errorf("unexpected first NAL: %x", nals[0])
+	}
+	if !bytes.Equal(nals[1], []byte{0x65, 0xBB, 0xCC}) {
+		t.Errorf("unexpected second NAL: %x", nals[1])
+	}
+}
+
+func TestH264FramerCachesParameterSetsAndPrependsToIDR(t *testing.T) {
+	f := NewH264Framer(nil)
+
+	sps := append([]byte{0, 0, 0, 1}, 0x67, 0x01)
+	out := f.Frame(sps)
+	if len(out) != 0 {
+		t.Fatalf("SPS alone should not produce output NALs, got %d", len(out))
+	}
+
+	pps := append([]byte{0, 0, 0, 1}, 0x68, 0x02)
+	out = f.Frame(pps)
+	if len(out) != 0 {
+		t.Fatalf("PPS alone should not produce output NALs, got %d", len(out))
+	}
+
+	cachedSPS, cachedPPS := f.CachedParameterSets()
+	if !bytes.Equal(cachedSPS, []byte{0x67, 0x01}) {
+		t.Errorf("unexpected cached SPS: %x", cachedSPS)
+	}
+	if !bytes.Equal(cachedPPS, []byte{0x68, 0x02}) {
+		t.Errorf("unexpected cached PPS: %x", cachedPPS)
+	}
+
+	idr := append([]byte{0, 0, 0, 1}, 0x65, 0xFF)
+	out = f.Frame(idr)
+	if len(out) != 3 {
+		t.Fatalf("expected SPS+PPS+IDR prepended, got %d NALs", len(out))
+	}
+	if !bytes.Equal(out[0], []byte{0x67, 0x01}) || !bytes.Equal(out[1], []byte{0x68, 0x02}) {
+		t.Errorf("expected cached SPS/PPS prepended before IDR, got %x / %x", out[0], out[1])
+	}
+	if !bytes.Equal(out[2], []byte{0x65, 0xFF}) {
+		t.Errorf("unexpected IDR NAL: %x", out[2])
+	}
+}
@@ -8,18 +8,17 @@
 package main
 
 import (
-	"bufio"
 	"context"
-	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/chindeo/screen"
+	"github.com/chindeo/screen/signal"
+	"github.com/pion/rtcp"
 	"github.com/pion/webrtc/v4"
 	"github.com/pion/webrtc/v4/pkg/media"
 	"github.com/pion/webrtc/v4/pkg/media/h264reader"
@@ -41,12 +40,6 @@ func main() { //nolint
 	}
 	defer connector.Close()
 
-	// 开始屏幕捕获
-	if err := connector.StartScreenCapture(); err != nil {
-		fmt.Printf("Failed to start screen capture: %v\n", err)
-		return
-	}
-	defer connector.StopScreenCapture()
 	if connector.Conn == nil {
 		fmt.Printf("Connection not established\n")
 		return
@@ -60,7 +53,10 @@ func main() { //nolint
 	}
 	fmt.Printf("Device Info: %+v\n", deviceInfo)
 
+	// Run负责接收数据包并分发给订阅者，SendToPipe作为一个订阅者把帧数据写入管道。
+	// 屏幕捕获的开始/停止由Subscribe/cancel按引用计数自动处理。
 	go connector.Run()
+	go connector.SendToPipe()
 
 	// Create a new RTCPeerConnection
 	peerConnection, err := webrtc.NewPeerConnection(webrtc.Configuration{})
@@ -86,15 +82,42 @@ func main() { //nolint
 		panic(videoTrackErr)
 	}
 
-	// Read incoming RTCP packets
-	// Before these packets are returned they are processed by interceptors. For things
-	// like NACK this needs to be called.
+	// Accept a reliable "control" DataChannel from the browser for reverse
+	// input injection (touch/key/text/rotate), same protocol whip.go serves.
+	peerConnection.OnDataChannel(func(channel *webrtc.DataChannel) {
+		if channel.Label() != "control" {
+			return
+		}
+		channel.OnMessage(func(msg webrtc.DataChannelMessage) {
+			if err := connector.HandleControlMessage(msg.Data); err != nil {
+				log.Printf("control message rejected: %s\n", err.Error())
+			}
+		})
+	})
+
+	// Read incoming RTCP packets. Before these packets are returned they are
+	// processed by interceptors - for things like NACK this needs to be called.
+	// REMB reports are additionally fed into a congestion controller that
+	// steps connector's encoder bitrate up/down across a fixed ladder.
+	congestionCtx, congestionCancel := context.WithCancel(context.Background())
+	defer congestionCancel()
+
+	congestionController := screen.NewCongestionController(connector)
+	go congestionController.Run(congestionCtx)
+
 	go func() {
 		rtcpBuf := make([]byte, 1500)
 		for {
-			if _, _, rtcpErr := rtpSender.Read(rtcpBuf); rtcpErr != nil {
+			n, _, rtcpErr := rtpSender.Read(rtcpBuf)
+			if rtcpErr != nil {
 				return
 			}
+
+			pkts, err := rtcp.Unmarshal(rtcpBuf[:n])
+			if err != nil {
+				continue
+			}
+			congestionController.Feed(pkts)
 		}
 	}()
 
@@ -142,27 +165,64 @@ func main() { //nolint
 		}
 	})
 
+	// Signaling is handled by a pluggable signal.Signaler. StdinSignaler keeps
+	// the original base64-paste workflow for debugging without a real
+	// signaling server; swap in signal.UpgradeHTTP for a WebSocket-backed one.
+	signaler := signal.NewStdinSignaler()
+
+	// Apply remote trickle ICE candidates as the signaler delivers them.
+	// StdinSignaler has no return channel and yields an already-closed
+	// channel here; WebSocketSignaler streams candidates as they arrive.
+	go func() {
+		for candidate := range signaler.RemoteCandidates() {
+			if err := peerConnection.AddICECandidate(webrtc.ICECandidateInit{
+				Candidate:        candidate.Candidate,
+				SDPMid:           candidate.SDPMid,
+				SDPMLineIndex:    candidate.SDPMLineIndex,
+				UsernameFragment: candidate.UsernameFragment,
+			}); err != nil {
+				log.Printf("failed to add remote ICE candidate: %s\n", err.Error())
+			}
+		}
+	}()
+
+	// Forward local trickle ICE candidates to the signaler as they're
+	// discovered instead of blocking on GatheringCompletePromise.
+	peerConnection.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c == nil {
+			return
+		}
+		init := c.ToJSON()
+		if err := signaler.OnICECandidate(context.Background(), signal.ICECandidateInit{
+			Candidate:        init.Candidate,
+			SDPMid:           init.SDPMid,
+			SDPMLineIndex:    init.SDPMLineIndex,
+			UsernameFragment: init.UsernameFragment,
+		}); err != nil {
+			log.Printf("failed to send ICE candidate: %s\n", err.Error())
+		}
+	})
+
 	// Set the handler for Peer connection state
 	// This will notify you when the peer has connected/disconnected
 	peerConnection.OnConnectionStateChange(func(s webrtc.PeerConnectionState) {
 		fmt.Printf("Peer Connection State has changed: %s\n", s.String())
 
 		if s == webrtc.PeerConnectionStateFailed {
-			// Wait until PeerConnection has had no network activity for 30 seconds or another failure.
-			// It may be reconnected using an ICE Restart. Use webrtc.PeerConnectionStateDisconnected
-			// if you are interested in detecting faster timeout. Note that the PeerConnection may come
-			// back from PeerConnectionStateDisconnected.
-			fmt.Println("Peer Connection has gone to failed exiting")
-			os.Exit(0)
+			// Attempt an ICE restart instead of exiting: the PeerConnection can
+			// recover without tearing down the whole capture session.
+			log.Println("Peer Connection failed, attempting ICE restart")
+			go restartICE(peerConnection, signaler)
 		}
 	})
 
-	// Wait for the offer to be pasted
-	offer := webrtc.SessionDescription{}
-	decode(readUntilNewline(), &offer)
+	offer, err := signaler.Offer(context.Background())
+	if err != nil {
+		panic(err)
+	}
 
 	// Set the remote SessionDescription
-	if err = peerConnection.SetRemoteDescription(offer); err != nil {
+	if err = peerConnection.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: offer.SDP}); err != nil {
 		panic(err)
 	}
 
@@ -172,65 +232,43 @@ func main() { //nolint
 		panic(err)
 	}
 
-	// Create channel that is blocked until ICE Gathering is complete
-	gatherComplete := webrtc.GatheringCompletePromise(peerConnection)
-
-	// Sets the LocalDescription, and starts our UDP listeners
+	// Sets the LocalDescription, and starts our UDP listeners. Candidates are
+	// trickled to the signaler via OnICECandidate above rather than waiting
+	// for gathering to complete.
 	if err = peerConnection.SetLocalDescription(answer); err != nil {
 		panic(err)
 	}
 
-	// Block until ICE Gathering is complete, disabling trickle ICE
-	// we do this because we only can exchange one signaling message
-	// in a production application you should exchange ICE Candidates via OnICECandidate
-	<-gatherComplete
-
-	// Output the answer in base64 so we can paste it in browser
-	fmt.Println(encode(peerConnection.LocalDescription()))
+	if err := signaler.Answer(context.Background(), signal.SessionDescription{Type: "answer", SDP: answer.SDP}); err != nil {
+		panic(err)
+	}
 
 	// Block forever
 	select {}
 }
 
-// Read from stdin until we get a newline.
-func readUntilNewline() (in string) {
-	var err error
-
-	r := bufio.NewReader(os.Stdin)
-	for {
-		in, err = r.ReadString('\n')
-		if err != nil && !errors.Is(err, io.EOF) {
-			panic(err)
-		}
-
-		if in = strings.TrimSpace(in); len(in) > 0 {
-			break
-		}
-	}
-
-	fmt.Println("")
-
-	return
-}
-
-// JSON encode + base64 a SessionDescription.
-func encode(obj *webrtc.SessionDescription) string {
-	b, err := json.Marshal(obj)
+// restartICE renegotiates the PeerConnection after it drops to failed: it
+// creates a fresh offer with ICERestart set and sends it out via
+// signaler.Renegotiate, which (unlike Answer/Offer) is meant for this
+// this-end-initiates-a-new-offer round-trip, then applies whatever answer
+// comes back.
+func restartICE(peerConnection *webrtc.PeerConnection, signaler signal.Signaler) {
+	restartOffer, err := peerConnection.CreateOffer(&webrtc.OfferOptions{ICERestart: true})
 	if err != nil {
-		panic(err)
+		log.Printf("failed to create ICE restart offer: %s\n", err.Error())
+		return
+	}
+	if err := peerConnection.SetLocalDescription(restartOffer); err != nil {
+		log.Printf("failed to set ICE restart offer: %s\n", err.Error())
+		return
 	}
 
-	return base64.StdEncoding.EncodeToString(b)
-}
-
-// Decode a base64 and unmarshal JSON into a SessionDescription.
-func decode(in string, obj *webrtc.SessionDescription) {
-	b, err := base64.StdEncoding.DecodeString(in)
+	restartAnswer, err := signaler.Renegotiate(context.Background(), signal.SessionDescription{Type: "offer", SDP: restartOffer.SDP})
 	if err != nil {
-		panic(err)
+		log.Printf("failed to renegotiate ICE restart: %s\n", err.Error())
+		return
 	}
-
-	if err = json.Unmarshal(b, obj); err != nil {
-		panic(err)
+	if err := peerConnection.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: restartAnswer.SDP}); err != nil {
+		log.Printf("failed to apply ICE restart answer: %s\n", err.Error())
 	}
 }
@@ -15,12 +15,6 @@ func main() {
 	}
 	defer connector.Close()
 
-	// 开始屏幕捕获
-	if err := connector.StartScreenCapture(); err != nil {
-		fmt.Printf("Failed to start screen capture: %v\n", err)
-		return
-	}
-	defer connector.StopScreenCapture()
 	if connector.Conn == nil {
 		fmt.Printf("Connection not established\n")
 		return
@@ -33,5 +27,9 @@ func main() {
 		return
 	}
 	fmt.Printf("Device Info: %+v\n", deviceInfo)
+
+	// Run负责接收数据包并分发给订阅者，SaveToDesk作为订阅者把帧数据写入磁盘。
+	// 屏幕捕获的开始/停止由Subscribe/cancel按引用计数自动处理。
+	go connector.Run()
 	connector.SaveToDesk()
 }
@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net"
 	"os"
 	"strings"
+	"sync"
 )
 
 // DeviceConnector 客户端用于与scrcpy_server通信
@@ -20,6 +22,15 @@ type DeviceConnector struct {
 	R *io.PipeReader
 	W *io.PipeWriter
 	F *os.File
+
+	mu          sync.Mutex
+	subscribers map[int]*subscriber
+	nextSubID   int
+	captureRefs int
+
+	// writeMu序列化对Conn的写入：SendCommand会被RTCP反馈、DataChannel控制
+	// 消息回调等多个goroutine并发调用，没有它header/payload可能被交错写入
+	writeMu sync.Mutex
 }
 
 // 命令类型（必须与服务器保持一致）
@@ -29,6 +40,13 @@ const (
 	CMD_START_SCREEN_CAPTURE = 3
 	CMD_STOP_SCREEN_CAPTURE  = 4
 	CMD_EXIT                 = 5
+	CMD_SET_BITRATE          = 6
+	CMD_SET_MAX_FPS          = 7
+	CMD_REQUEST_IDR          = 8
+	CMD_INJECT_TOUCH         = 9
+	CMD_INJECT_KEY           = 10
+	CMD_INJECT_TEXT          = 11
+	CMD_ROTATE               = 12
 )
 
 // 数据包类型（服务器 -> 客户端）
@@ -104,6 +122,14 @@ func (dc *DeviceConnector) Close() {
 	if dc.F != nil {
 		dc.F.Close()
 	}
+
+	dc.mu.Lock()
+	for id, sub := range dc.subscribers {
+		delete(dc.subscribers, id)
+		close(sub.ch)
+	}
+	dc.captureRefs = 0
+	dc.mu.Unlock()
 }
 
 // SendCommand 发送命令到服务器
@@ -117,7 +143,11 @@ func (dc *DeviceConnector) SendCommand(cmdType int, payload []byte) error {
 	header[0] = byte(cmdType)
 	binary.LittleEndian.PutUint32(header[1:], uint32(len(payload)))
 
-	// 发送头部和负载
+	// 发送头部和负载。加写锁防止并发调用者（RTCP反馈、控制消息回调等）
+	// 交错写入各自的header/payload，破坏命令流
+	dc.writeMu.Lock()
+	defer dc.writeMu.Unlock()
+
 	if _, err := dc.Conn.Write(header); err != nil {
 		return err
 	}
@@ -233,31 +263,62 @@ func (dc *DeviceConnector) Exit() error {
 	return dc.SendCommand(CMD_EXIT, nil)
 }
 
-func (sr *DeviceConnector) SendToPipe() {
-	// 接收帧数据
-	for {
-		// 接收头部
-		header := make([]byte, 5)
-		if _, err := io.ReadFull(sr.Conn, header); err != nil {
-			log.Printf("ReadFull get error %s\n", err.Error())
-			continue
-		}
+// SetBitrate 下发目标码率（单位 bps），用于拥塞控制动态调整编码码率
+func (dc *DeviceConnector) SetBitrate(bps int) error {
+	payload := make([]byte, 4)
+	binary.LittleEndian.PutUint32(payload, uint32(bps))
+	return dc.SendCommand(CMD_SET_BITRATE, payload)
+}
 
-		pktType := int(header[0])
-		if pktType != PKT_SCREEN_FRAME {
-			// fmt.Printf("Received unknown packet type: %d\n", pktType)
-			continue
-		}
+// SetFPS 下发最大帧率
+func (dc *DeviceConnector) SetFPS(n int) error {
+	payload := make([]byte, 4)
+	binary.LittleEndian.PutUint32(payload, uint32(n))
+	return dc.SendCommand(CMD_SET_MAX_FPS, payload)
+}
 
-		length := binary.LittleEndian.Uint32(header[1:])
-		log.Printf("Received packet length: %d, type: %d\n", length, pktType)
+// InjectTouch 注入一次触摸事件，screenW/screenH是事件坐标所基于的屏幕尺寸，
+// 供服务器在实际设备分辨率与之不同时缩放坐标
+func (dc *DeviceConnector) InjectTouch(action, pointerID uint8, x, y int32, pressure float32, screenW, screenH int32) error {
+	payload := make([]byte, touchEventSize)
+	payload[0] = action
+	payload[1] = pointerID
+	binary.LittleEndian.PutUint32(payload[2:6], uint32(x))
+	binary.LittleEndian.PutUint32(payload[6:10], uint32(y))
+	binary.LittleEndian.PutUint32(payload[10:14], math.Float32bits(pressure))
+	binary.LittleEndian.PutUint32(payload[14:18], uint32(screenW))
+	binary.LittleEndian.PutUint32(payload[18:22], uint32(screenH))
+	return dc.SendCommand(CMD_INJECT_TOUCH, payload)
+}
 
-		data := make([]byte, length)
-		if _, err := io.ReadFull(sr.Conn, data); err != nil {
-			log.Printf("ReadFull get error %s\n", err.Error())
-			continue
-		}
-		n, err := sr.W.Write(data)
+// InjectKey 注入一次按键事件
+func (dc *DeviceConnector) InjectKey(action uint8, keycode, metastate int32) error {
+	payload := make([]byte, keyEventSize)
+	payload[0] = action
+	binary.LittleEndian.PutUint32(payload[1:5], uint32(keycode))
+	binary.LittleEndian.PutUint32(payload[5:9], uint32(metastate))
+	return dc.SendCommand(CMD_INJECT_KEY, payload)
+}
+
+// InjectText 注入一段UTF-8文本，相当于依次对每个字符做按键注入但由服务器
+// 一次性处理，适合输入法场景
+func (dc *DeviceConnector) InjectText(text string) error {
+	return dc.SendCommand(CMD_INJECT_TEXT, []byte(text))
+}
+
+// Rotate 请求设备切换到给定的屏幕方向
+func (dc *DeviceConnector) Rotate(orientation uint8) error {
+	return dc.SendCommand(CMD_ROTATE, []byte{orientation})
+}
+
+// SendToPipe 以一个订阅者的身份消费帧数据，并写入sr.W供h264reader读取。
+// 捕获由Run()统一接收并分发，SendToPipe不再直接读取socket。
+func (sr *DeviceConnector) SendToPipe() {
+	_, ch, cancel := sr.Subscribe()
+	defer cancel()
+
+	for pkt := range ch {
+		n, err := sr.W.Write(pkt.Data)
 		if err != nil {
 			log.Printf("Write get error %s\n", err.Error())
 		}
@@ -265,6 +326,8 @@ func (sr *DeviceConnector) SendToPipe() {
 	}
 }
 
+// SaveToDesk 以一个订阅者的身份消费帧数据，并写入本地文件。
+// 捕获由Run()统一接收并分发，SaveToDesk不再直接读取socket。
 func (sr *DeviceConnector) SaveToDesk() {
 	if sr.F == nil {
 		f, err := os.Create("./output.h264")
@@ -273,30 +336,12 @@ func (sr *DeviceConnector) SaveToDesk() {
 		}
 		sr.F = f
 	}
-	// 接收帧数据
-	for {
-		// 接收头部
-		header := make([]byte, 5)
-		if _, err := io.ReadFull(sr.Conn, header); err != nil {
-			log.Printf("ReadFull get error %s\n", err.Error())
-			continue
-		}
 
-		pktType := int(header[0])
-		if pktType != PKT_SCREEN_FRAME {
-			// fmt.Printf("Received unknown packet type: %d\n", pktType)
-			continue
-		}
-
-		length := binary.LittleEndian.Uint32(header[1:])
-		log.Printf("Received packet length: %d, type: %d\n", length, pktType)
+	_, ch, cancel := sr.Subscribe()
+	defer cancel()
 
-		data := make([]byte, length)
-		if _, err := io.ReadFull(sr.Conn, data); err != nil {
-			log.Printf("ReadFull get error %s\n", err.Error())
-			continue
-		}
-		n, err := sr.F.Write(data)
+	for pkt := range ch {
+		n, err := sr.F.Write(pkt.Data)
 		if err != nil {
 			log.Printf("Write get error %s\n", err.Error())
 		}
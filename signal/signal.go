@@ -0,0 +1,42 @@
+// Package signal定义了为屏幕捕获会话建立WebRTC连接所需的信令抽象。
+// 不同部署场景需要不同的信令传输（WebSocket、或调试用的stdin粘贴），
+// 但协商流程本身——取得offer、送回answer、交换trickle ICE候选——是一样的，
+// 所以把它收敛成一个Signaler接口，让上层按需插拔具体实现。WHEP本身已经是
+// 一次HTTP offer/answer round-trip，不需要再套一层Signaler，直接由
+// screen.WHIPServer的/whep/端点处理（见whip.go）。
+package signal
+
+import "context"
+
+// SessionDescription镜像webrtc.SessionDescription，让本包不必直接依赖pion/webrtc
+type SessionDescription struct {
+	Type string `json:"type"`
+	SDP  string `json:"sdp"`
+}
+
+// ICECandidateInit镜像webrtc.ICECandidateInit，用于trickle ICE候选的传输
+type ICECandidateInit struct {
+	Candidate        string  `json:"candidate"`
+	SDPMid           *string `json:"sdpMid,omitempty"`
+	SDPMLineIndex    *uint16 `json:"sdpMLineIndex,omitempty"`
+	UsernameFragment *string `json:"usernameFragment,omitempty"`
+}
+
+// Signaler是一种信令传输：取得远端的SDP offer、把本地SDP answer发回去、
+// 并双向交换trickle ICE候选。实现只需要关心自己的传输细节（HTTP、WebSocket、
+// stdin……），协商逻辑留给调用方统一处理
+type Signaler interface {
+	// Offer阻塞直到拿到远端的SDP offer
+	Offer(ctx context.Context) (SessionDescription, error)
+	// Answer把本地SDP answer发送给远端
+	Answer(ctx context.Context, answer SessionDescription) error
+	// OnICECandidate把一个本地trickle ICE候选发送给远端
+	OnICECandidate(ctx context.Context, candidate ICECandidateInit) error
+	// Renegotiate发送一个由本端发起的新offer（典型地用于ICE restart），
+	// 阻塞直到对端送回匹配的answer。和Offer/Answer是反过来的角色，不能用
+	// Answer代替——Answer的实现假定自己永远是在回应对端的offer
+	Renegotiate(ctx context.Context, offer SessionDescription) (SessionDescription, error)
+	// RemoteCandidates返回一个只读channel，持续推送对端发来的trickle ICE
+	// 候选，在信令传输本身不支持接收候选时返回一个立即关闭的channel
+	RemoteCandidates() <-chan ICECandidateInit
+}
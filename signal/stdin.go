@@ -0,0 +1,126 @@
+package signal
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// StdinSignaler 通过在终端里手动粘贴base64编码的SDP交换offer/answer，
+// 是WebSocketSignaler/WHEP之前这个模块唯一支持的方式，现在仅作为没有真实
+// 信令服务器时的调试手段保留：它不支持真正的trickle ICE，OnICECandidate
+// 只是把候选打印出来供排查
+type StdinSignaler struct {
+	reader *bufio.Reader
+}
+
+// NewStdinSignaler 创建一个从os.Stdin读取的StdinSignaler
+func NewStdinSignaler() *StdinSignaler {
+	return &StdinSignaler{reader: bufio.NewReader(os.Stdin)}
+}
+
+// Offer 等待用户粘贴一行base64编码的SDP offer并回车
+func (s *StdinSignaler) Offer(ctx context.Context) (SessionDescription, error) {
+	fmt.Println("Paste the browser's base64 SDP offer, then hit enter:")
+
+	line, err := s.readUntilNewline()
+	if err != nil {
+		return SessionDescription{}, err
+	}
+
+	var sd SessionDescription
+	if err := decode(line, &sd); err != nil {
+		return SessionDescription{}, err
+	}
+	return sd, nil
+}
+
+// Answer 把本地SDP answer编码成base64并打印，供用户粘贴回浏览器
+func (s *StdinSignaler) Answer(ctx context.Context, answer SessionDescription) error {
+	encoded, err := encode(answer)
+	if err != nil {
+		return err
+	}
+	fmt.Println(encoded)
+	return nil
+}
+
+// OnICECandidate 打印本地trickle ICE候选。StdinSignaler没有持续的信令通道
+// 可以把它送回浏览器，这里仅用于调试时观察候选是否产生
+func (s *StdinSignaler) OnICECandidate(ctx context.Context, candidate ICECandidateInit) error {
+	log.Printf("local ICE candidate (not sent, stdin signaling has no return channel): %s\n", candidate.Candidate)
+	return nil
+}
+
+// Renegotiate 把本端发起的新offer（例如ICE restart）打印成base64供粘贴到
+// 浏览器，再等待用户粘贴回对应的answer，和Offer/Answer是反过来的一问一答
+func (s *StdinSignaler) Renegotiate(ctx context.Context, offer SessionDescription) (SessionDescription, error) {
+	encoded, err := encode(offer)
+	if err != nil {
+		return SessionDescription{}, err
+	}
+	fmt.Println("Paste this base64 SDP offer into the browser, then paste back its answer:")
+	fmt.Println(encoded)
+
+	line, err := s.readUntilNewline()
+	if err != nil {
+		return SessionDescription{}, err
+	}
+
+	var answer SessionDescription
+	if err := decode(line, &answer); err != nil {
+		return SessionDescription{}, err
+	}
+	return answer, nil
+}
+
+// RemoteCandidates 对StdinSignaler是空实现：stdin没有持续连接可以收候选，
+// 返回一个立即关闭的channel
+func (s *StdinSignaler) RemoteCandidates() <-chan ICECandidateInit {
+	ch := make(chan ICECandidateInit)
+	close(ch)
+	return ch
+}
+
+// readUntilNewline从stdin读取直到拿到一行非空内容
+func (s *StdinSignaler) readUntilNewline() (string, error) {
+	for {
+		in, err := s.reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+
+		if in = strings.TrimSpace(in); len(in) > 0 {
+			return in, nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// encode把SessionDescription编码成JSON再base64
+func encode(sd SessionDescription) (string, error) {
+	b, err := json.Marshal(sd)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// decode把base64字符串解出JSON并填充到sd
+func decode(in string, sd *SessionDescription) error {
+	b, err := base64.StdEncoding.DecodeString(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, sd)
+}
@@ -0,0 +1,144 @@
+package signal
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	// 信令端点通常被部署为反向代理后面的独立服务，这里不对浏览器发起方做限制
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// envelope是WebSocket信令通道上交换的JSON信封：{type: "offer"|"answer"|"candidate", sdp|candidate}
+type envelope struct {
+	Type      string            `json:"type"`
+	SDP       string            `json:"sdp,omitempty"`
+	Candidate *ICECandidateInit `json:"candidate,omitempty"`
+}
+
+// WebSocketSignaler通过一条已升级的WebSocket连接交换offer/answer/candidate。
+// 升级后立即启动一个读循环，把收到的offer/candidate分别投递到各自的channel，
+// 这样Offer()和远端候选的消费者可以并发读取而不必争抢同一个连接
+type WebSocketSignaler struct {
+	conn       *websocket.Conn
+	offers     chan SessionDescription
+	answers    chan SessionDescription
+	candidates chan ICECandidateInit
+	errs       chan error
+}
+
+// UpgradeHTTP把一次HTTP请求升级为WebSocket连接，返回绑定其上的WebSocketSignaler
+func UpgradeHTTP(w http.ResponseWriter, r *http.Request) (*WebSocketSignaler, error) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &WebSocketSignaler{
+		conn:       conn,
+		offers:     make(chan SessionDescription, 1),
+		answers:    make(chan SessionDescription, 1),
+		candidates: make(chan ICECandidateInit, 16),
+		errs:       make(chan error, 1),
+	}
+	go s.readLoop()
+	return s, nil
+}
+
+func (s *WebSocketSignaler) readLoop() {
+	defer close(s.candidates)
+	for {
+		var msg envelope
+		if err := s.conn.ReadJSON(&msg); err != nil {
+			select {
+			case s.errs <- err:
+			default:
+			}
+			return
+		}
+
+		switch msg.Type {
+		case "offer":
+			select {
+			case s.offers <- SessionDescription{Type: "offer", SDP: msg.SDP}:
+			default:
+			}
+		case "answer":
+			select {
+			case s.answers <- SessionDescription{Type: "answer", SDP: msg.SDP}:
+			default:
+			}
+		case "candidate":
+			if msg.Candidate == nil {
+				continue
+			}
+			// 没有消费者及时取用时丢弃最旧的候选，而不是阻塞读循环——否则
+			// 后续的offer/answer/candidate消息也会被卡住读不到
+			select {
+			case s.candidates <- *msg.Candidate:
+			default:
+				select {
+				case <-s.candidates:
+				default:
+				}
+				select {
+				case s.candidates <- *msg.Candidate:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// Offer阻塞直到收到一条offer消息，或连接出错/ctx被取消
+func (s *WebSocketSignaler) Offer(ctx context.Context) (SessionDescription, error) {
+	select {
+	case offer := <-s.offers:
+		return offer, nil
+	case err := <-s.errs:
+		return SessionDescription{}, err
+	case <-ctx.Done():
+		return SessionDescription{}, ctx.Err()
+	}
+}
+
+// Answer把本地answer以{type:"answer", sdp}发送给对端
+func (s *WebSocketSignaler) Answer(ctx context.Context, answer SessionDescription) error {
+	return s.conn.WriteJSON(envelope{Type: "answer", SDP: answer.SDP})
+}
+
+// OnICECandidate把一个本地trickle候选以{type:"candidate", candidate}发送给对端
+func (s *WebSocketSignaler) OnICECandidate(ctx context.Context, candidate ICECandidateInit) error {
+	return s.conn.WriteJSON(envelope{Type: "candidate", Candidate: &candidate})
+}
+
+// Renegotiate把本端发起的新offer（例如ICE restart）以{type:"offer", sdp}
+// 发送给对端，阻塞直到收到对应的answer消息、连接出错或ctx被取消
+func (s *WebSocketSignaler) Renegotiate(ctx context.Context, offer SessionDescription) (SessionDescription, error) {
+	if err := s.conn.WriteJSON(envelope{Type: "offer", SDP: offer.SDP}); err != nil {
+		return SessionDescription{}, err
+	}
+
+	select {
+	case answer := <-s.answers:
+		return answer, nil
+	case err := <-s.errs:
+		return SessionDescription{}, err
+	case <-ctx.Done():
+		return SessionDescription{}, ctx.Err()
+	}
+}
+
+// RemoteCandidates返回一个只读channel，持续推送对端发来的trickle ICE候选，
+// 直到连接关闭
+func (s *WebSocketSignaler) RemoteCandidates() <-chan ICECandidateInit {
+	return s.candidates
+}
+
+// Close关闭底层WebSocket连接
+func (s *WebSocketSignaler) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,109 @@
+package screen
+
+import (
+	"log"
+)
+
+// subscriberQueueSize 是每个订阅者缓冲队列的容量，队列满时丢弃最旧的包
+const subscriberQueueSize = 32
+
+// Packet 是从scrcpy_server接收到的一个数据包，分发给各订阅者
+type Packet struct {
+	Type int
+	Data []byte
+}
+
+// subscriber 是Subscribe返回的内部订阅记录
+type subscriber struct {
+	id int
+	ch chan Packet
+}
+
+// Subscribe 注册一个新的订阅者，返回其id、只读接收通道，以及用于取消订阅的cancel函数。
+// 只要至少有一个订阅者存在，屏幕捕获就会保持运行；当最后一个订阅者取消时自动停止捕获。
+func (dc *DeviceConnector) Subscribe() (id int, ch <-chan Packet, cancel func()) {
+	dc.mu.Lock()
+	if dc.subscribers == nil {
+		dc.subscribers = make(map[int]*subscriber)
+	}
+
+	dc.nextSubID++
+	id = dc.nextSubID
+	sub := &subscriber{id: id, ch: make(chan Packet, subscriberQueueSize)}
+	dc.subscribers[id] = sub
+
+	dc.captureRefs++
+	firstSubscriber := dc.captureRefs == 1
+	dc.mu.Unlock()
+
+	if firstSubscriber {
+		if err := dc.StartScreenCapture(); err != nil {
+			log.Printf("failed to start screen capture: %s\n", err.Error())
+		}
+	}
+
+	return id, sub.ch, func() { dc.unsubscribe(id) }
+}
+
+func (dc *DeviceConnector) unsubscribe(id int) {
+	dc.mu.Lock()
+	sub, ok := dc.subscribers[id]
+	if !ok {
+		dc.mu.Unlock()
+		return
+	}
+	delete(dc.subscribers, id)
+	close(sub.ch)
+
+	dc.captureRefs--
+	lastSubscriber := dc.captureRefs <= 0
+	dc.mu.Unlock()
+
+	if lastSubscriber {
+		// 只停止捕获，不发送Exit：DeviceConnector和底层TCP连接是长期存活的，
+		// 后续还会有新的订阅者（例如WHIP/WHEP的下一个观看者）重新开始捕获。
+		// Close()也只关闭本地连接，不向服务器发送Exit。
+		if err := dc.StopScreenCapture(); err != nil {
+			log.Printf("failed to stop screen capture: %s\n", err.Error())
+		}
+	}
+}
+
+// broadcast 把一个数据包派发给所有当前订阅者，订阅者队列已满时丢弃其最旧的包
+func (dc *DeviceConnector) broadcast(pkt Packet) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	for _, sub := range dc.subscribers {
+		select {
+		case sub.ch <- pkt:
+		default:
+			select {
+			case <-sub.ch:
+			default:
+			}
+			select {
+			case sub.ch <- pkt:
+			default:
+			}
+		}
+	}
+}
+
+// Run 启动唯一的接收循环，解析scrcpy_server发来的数据包并分发给所有订阅者。
+// Run会一直阻塞直到连接出错或关闭，应以goroutine的形式调用。
+func (dc *DeviceConnector) Run() {
+	for {
+		pktType, data, err := dc.RecvPacket()
+		if err != nil {
+			log.Printf("RecvPacket get error %s\n", err.Error())
+			return
+		}
+
+		if pktType != PKT_SCREEN_FRAME {
+			continue
+		}
+
+		dc.broadcast(Packet{Type: pktType, Data: data})
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/chindeo/screen"
+)
+
+func main() {
+	connector := screen.NewDeviceConnector("192.168.20.156", 12345)
+	// 连接到服务器
+	if err := connector.Connect(); err != nil {
+		fmt.Printf("Failed to connect: %v\n", err)
+		return
+	}
+	defer connector.Close()
+
+	// 查询设备信息
+	deviceInfo, err := connector.QueryDeviceInfo()
+	if err != nil {
+		fmt.Printf("Failed to query device info: %v\n", err)
+		return
+	}
+	fmt.Printf("Device Info: %+v\n", deviceInfo)
+
+	go connector.Run()
+
+	whipServer := screen.NewWHIPServer(connector)
+
+	fmt.Println("WHIP endpoint listening on :8080/whip/{stream}, WHEP on :8080/whep/{stream}")
+	if err := http.ListenAndServe(":8080", whipServer.Handler()); err != nil {
+		fmt.Printf("WHIP server stopped: %v\n", err)
+	}
+}
@@ -0,0 +1,271 @@
+package screen
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// h264FrameDuration 是送入WebRTC track的每个样本的估计时长，用于分页播放
+const h264FrameDuration = time.Millisecond * 33
+
+// controlChannelLabel 是浏览器端用于反向输入注入的可靠DataChannel的标签
+const controlChannelLabel = "control"
+
+// whipSession 代表一个通过WHIP建立的WebRTC会话
+type whipSession struct {
+	resource string
+	pc       *webrtc.PeerConnection
+	track    *webrtc.TrackLocalStaticSample
+	sender   *webrtc.RTPSender
+}
+
+// WHIPServer 将DeviceConnector捕获的H.264流以WHIP（WebRTC-HTTP Ingestion
+// Protocol）的形式对外提供，兼容OBS、mediamtx等WHIP客户端，同时在/whep/下
+// 挂载同样的协商逻辑供WHEP拉流观看端使用，无需再通过stdin粘贴base64 SDP。
+// 每个会话的RTCP PLI/FIR都会转化为对源端的RequestKeyframe，使浏览器端的
+// 丢包恢复或迟到加入能尽快拿到新的IDR。
+type WHIPServer struct {
+	dc     *DeviceConnector
+	framer *H264Framer
+
+	mu          sync.Mutex
+	sessions    map[string]*whipSession
+	unsubscribe func()
+}
+
+// NewWHIPServer 创建一个包装给定DeviceConnector的WHIPServer
+func NewWHIPServer(dc *DeviceConnector) *WHIPServer {
+	return &WHIPServer{
+		dc:       dc,
+		framer:   NewH264Framer(dc),
+		sessions: make(map[string]*whipSession),
+	}
+}
+
+// Handler 返回处理WHIP/WHEP请求的http.Handler：/whip/{stream}用于推流端，
+// /whep/{stream}用于拉流观看端，二者协商过程完全一致（都是offer/answer的
+// 一次HTTP round-trip），只是路径前缀和Location不同，所以共用同一套会话管理
+func (s *WHIPServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/whip/", s.handleWHIP("/whip/"))
+	mux.HandleFunc("/whep/", s.handleWHIP("/whep/"))
+	return mux
+}
+
+func (s *WHIPServer) handleWHIP(prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stream := r.URL.Path[len(prefix):]
+
+		switch r.Method {
+		case http.MethodPost:
+			s.handleOffer(w, r, stream, prefix)
+		case http.MethodDelete:
+			s.handleTeardown(w, r, stream)
+		default:
+			w.Header().Set("Allow", "POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *WHIPServer) handleOffer(w http.ResponseWriter, r *http.Request, stream, prefix string) {
+	if ct := r.Header.Get("Content-Type"); ct != "application/sdp" {
+		http.Error(w, "Content-Type must be application/sdp", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read offer: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to create peer connection: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	pc.OnDataChannel(func(channel *webrtc.DataChannel) {
+		if channel.Label() != controlChannelLabel {
+			return
+		}
+		channel.OnMessage(func(msg webrtc.DataChannelMessage) {
+			if err := s.dc.HandleControlMessage(msg.Data); err != nil {
+				log.Printf("control message from %s rejected: %s\n", stream, err.Error())
+			}
+		})
+	})
+
+	track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264}, "video", stream)
+	if err != nil {
+		pc.Close()
+		http.Error(w, fmt.Sprintf("failed to create track: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	sender, err := pc.AddTrack(track)
+	if err != nil {
+		pc.Close()
+		http.Error(w, fmt.Sprintf("failed to add track: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err := pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: string(body)}); err != nil {
+		pc.Close()
+		http.Error(w, fmt.Sprintf("failed to set remote description: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		pc.Close()
+		http.Error(w, fmt.Sprintf("failed to create answer: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	gatherComplete := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		pc.Close()
+		http.Error(w, fmt.Sprintf("failed to set local description: %v", err), http.StatusInternalServerError)
+		return
+	}
+	<-gatherComplete
+
+	resource, err := s.addSession(stream, pc, track, sender)
+	if err != nil {
+		pc.Close()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	go s.readRTCP(resource, sender)
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		if state == webrtc.PeerConnectionStateFailed || state == webrtc.PeerConnectionStateClosed || state == webrtc.PeerConnectionStateDisconnected {
+			s.removeSession(resource)
+		}
+	})
+
+	w.Header().Set("Content-Type", "application/sdp")
+	w.Header().Set("Location", prefix+resource)
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write([]byte(pc.LocalDescription().SDP))
+}
+
+func (s *WHIPServer) handleTeardown(w http.ResponseWriter, r *http.Request, resource string) {
+	if !s.removeSession(resource) {
+		http.Error(w, "no such session", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// addSession 注册一个新会话，并在这是第一个会话时以订阅者身份启动屏幕捕获。
+// 若捕获已在为其它会话运行，则立即把缓存的SPS/PPS推给新track；新track
+// 仍要等到下一个IDR才能开始解码。
+func (s *WHIPServer) addSession(stream string, pc *webrtc.PeerConnection, track *webrtc.TrackLocalStaticSample, sender *webrtc.RTPSender) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resource, err := newSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	if len(s.sessions) == 0 {
+		_, ch, cancel := s.dc.Subscribe()
+		s.unsubscribe = cancel
+		go s.pump(ch)
+	} else if sps, pps := s.framer.CachedParameterSets(); sps != nil {
+		if werr := track.WriteSample(media.Sample{Data: sps, Duration: h264FrameDuration}); werr != nil {
+			log.Printf("failed to write cached SPS to %s: %s\n", resource, werr.Error())
+		}
+		if pps != nil {
+			if werr := track.WriteSample(media.Sample{Data: pps, Duration: h264FrameDuration}); werr != nil {
+				log.Printf("failed to write cached PPS to %s: %s\n", resource, werr.Error())
+			}
+		}
+	}
+
+	s.sessions[resource] = &whipSession{resource: resource, pc: pc, track: track, sender: sender}
+	return resource, nil
+}
+
+// removeSession 注销一个会话，并在这是最后一个会话时取消订阅、停止屏幕捕获
+func (s *WHIPServer) removeSession(resource string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[resource]
+	if !ok {
+		return false
+	}
+	delete(s.sessions, resource)
+	go sess.pc.Close()
+
+	if len(s.sessions) == 0 && s.unsubscribe != nil {
+		s.unsubscribe()
+		s.unsubscribe = nil
+	}
+	return true
+}
+
+// pump 消费订阅者通道中的原始帧数据，通过framer切分NAL单元（自动在每个IDR
+// 前补齐缓存的SPS/PPS），分发给所有活跃会话的track
+func (s *WHIPServer) pump(ch <-chan Packet) {
+	for pkt := range ch {
+		for _, nal := range s.framer.Frame(pkt.Data) {
+			s.mu.Lock()
+			for _, sess := range s.sessions {
+				if werr := sess.track.WriteSample(media.Sample{Data: nal, Duration: h264FrameDuration}); werr != nil {
+					log.Printf("failed to write sample to %s: %s\n", sess.resource, werr.Error())
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// readRTCP 读取某个会话RTPSender上的RTCP反馈，把PLI/FIR转化为对源端的
+// RequestKeyframe，使该浏览器能尽快拿到新的IDR
+func (s *WHIPServer) readRTCP(resource string, sender *webrtc.RTPSender) {
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := sender.Read(buf)
+		if err != nil {
+			return
+		}
+
+		pkts, err := rtcp.Unmarshal(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		for _, pkt := range pkts {
+			switch pkt.(type) {
+			case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+				if err := s.framer.RequestKeyframe(); err != nil {
+					log.Printf("failed to request keyframe for %s: %s\n", resource, err.Error())
+				}
+			}
+		}
+	}
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}